@@ -10,10 +10,16 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
-	"golang.org/x/sync/errgroup"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ryan-ray/demyst/workerpool"
 )
 
 const apiStub = "https://jsonplaceholder.typicode.com/todos"
@@ -25,38 +31,140 @@ type ToDoItem struct {
 	Completed bool   `json:"completed"`
 }
 
-var ErrNon200Response = errors.New("non 200 response")
-
-func ToDoFetcher(client *http.Client, urlFn URLGenerator) func(context.Context) ([]byte, error) {
-	return func(ctx context.Context) ([]byte, error) {
-		var buf bytes.Buffer
-
-		endpoint := urlFn()
-		slog.Debug("GET", "endpoint", endpoint)
-		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-		if err != nil {
-			return nil, err
+// ToDoFetcher returns a fetch function that GETs the given endpoint,
+// retrying transient failures (network errors, 429s, 5xxs) per retry and
+// waiting on limiter before every attempt so callers don't exceed a given
+// host's quota. A nil limiter disables rate limiting.
+func ToDoFetcher(client *http.Client, retry RetryPolicy, limiter *HostRateLimiter, metrics *Metrics) func(context.Context, string) ([]byte, error) {
+	return func(ctx context.Context, endpoint string) ([]byte, error) {
+		host := endpoint
+		if u, err := url.Parse(endpoint); err == nil {
+			host = u.Host
 		}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, err
+		start := time.Now()
+		var lastErr error
+
+		for attempt := 1; ; attempt++ {
+			if err := limiter.Wait(ctx, host); err != nil {
+				return nil, err
+			}
+
+			data, retryAfter, err := doFetch(contextWithAttempt(ctx, attempt), client, endpoint)
+			if err == nil {
+				return data, nil
+			}
+			lastErr = err
+
+			if !isRetryable(err) || retry.exhausted(attempt, time.Since(start)) {
+				return nil, lastErr
+			}
+
+			metrics.incRetry()
+			delay := retry.nextDelay(attempt, retryAfter)
+			slog.Debug("Retrying", "endpoint", endpoint, "attempt", attempt, "delay", delay, "error", err)
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
 		}
+	}
+}
 
-		if resp.StatusCode != http.StatusOK {
-			slog.Error("Response", "endpoint", endpoint, "status", resp.StatusCode)
-			return nil, ErrNon200Response
-		}
+// doFetch performs a single GET attempt, returning the body, the duration
+// from a Retry-After header if one was sent, and any error.
+func doFetch(ctx context.Context, client *http.Client, endpoint string) ([]byte, time.Duration, error) {
+	var buf bytes.Buffer
+
+	slog.Debug("GET", "endpoint", endpoint)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		if _, err := io.Copy(&buf, resp.Body); err != nil {
-			return nil, err
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Response", "endpoint", endpoint, "status", resp.StatusCode)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return nil, retryAfter, &ResponseError{
+			StatusCode: resp.StatusCode,
+			Retryable:  retryableStatus(resp.StatusCode),
 		}
-		resp.Body.Close()
+	}
+
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		return nil, 0, err
+	}
+
+	return buf.Bytes(), 0, nil
+}
+
+// isRetryable reports whether err is worth retrying. Network-level errors
+// (timeouts, connection resets) are treated as transient; classified
+// response errors defer to their own verdict.
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrTerminalResponse) {
+		return false
+	}
+	return true
+}
+
+// httpFetcher adapts a closure-style fetch function, such as one returned
+// by ToDoFetcher, to workerpool.Fetcher[string]. When maxWait is positive,
+// it imposes a soft per-request deadline on top of ctx: a slow endpoint
+// times out on its own without needing the whole run to be aborted, and
+// the item is simply reported as a failed Result.
+type httpFetcher struct {
+	fetch   func(context.Context, string) ([]byte, error)
+	maxWait time.Duration
+}
+
+func (f httpFetcher) Fetch(ctx context.Context, endpoint string) ([]byte, error) {
+	if f.maxWait <= 0 {
+		return f.fetch(ctx, endpoint)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, f.maxWait)
+	defer cancel()
+
+	return f.fetch(reqCtx, endpoint)
+}
+
+// toDoDecoder is a workerpool.Decoder[*ToDoItem] that unmarshals a fetched
+// ToDo payload and rejects the zero-value placeholder items the API
+// occasionally returns. A nil metrics is fine; Metrics' methods are nil-safe.
+type toDoDecoder struct {
+	metrics *Metrics
+}
 
-		return buf.Bytes(), nil
+func (d toDoDecoder) Decode(data []byte) (*ToDoItem, error) {
+	item := &ToDoItem{}
+	if err := json.Unmarshal(data, item); err != nil {
+		d.metrics.incDecodeError()
+		return nil, err
 	}
+
+	// Generally speaking I would want to handle this case more gracefully,
+	// but it would massively depend on the business context. In this case,
+	// if there's an error with the payload, we are just going to log it
+	// and move on.
+	if item.ID <= 0 {
+		d.metrics.incDecodeError()
+		return nil, fmt.Errorf("item id %d: %w", item.ID, errInvalidItemID)
+	}
+
+	return item, nil
 }
 
+var errInvalidItemID = errors.New("item id less than or equal to zero")
+
 type URLGenerator func() string
 
 // Even will generate a url with an ID that increments by 2 on each call.
@@ -94,16 +202,44 @@ func main() {
 		count               int
 		httpTimeout         int
 		applicationDeadline int
+		retries             int
+		retryMaxElapsed     int
+		rps                 float64
+		burst               int
+		output              string
+		out                 string
+		urls                stringSliceFlag
+		maxWait             time.Duration
+		statePath           string
+		force               bool
+		metricsAddr         string
+		otlpEndpoint        string
 	)
 
 	fs := flag.NewFlagSet("todofetcher", flag.ExitOnError)
-	fs.IntVar(&count, "count", 20, "The amount of todo items to fetch")
+	fs.IntVar(&count, "count", 20, "The amount of todo items to fetch (per source, in -u fan-in mode)")
 	fs.IntVar(&workerCount, "workers", 5, "The amount of concurrent workers to use")
 	fs.BoolVar(&debug, "debug", false, "Print debugging output to stdout")
 	fs.IntVar(&httpTimeout, "timeout", 30, "The timeout in seconds for the HTTP client config")
 	fs.IntVar(&applicationDeadline, "deadline", 180, "The timeout for the application to complete processing")
+	fs.IntVar(&retries, "retries", 5, "The max number of attempts for a single fetch, including the first")
+	fs.IntVar(&retryMaxElapsed, "retry-max-elapsed", 120, "The max total seconds to spend retrying a single fetch")
+	fs.Float64Var(&rps, "rps", 0, "Requests per second to allow per host (0 disables rate limiting)")
+	fs.IntVar(&burst, "burst", 1, "Burst size for the per-host rate limiter")
+	fs.StringVar(&output, "output", "text", "Output format: text, ndjson, csv, or null")
+	fs.StringVar(&out, "out", "-", "Output path, or - for stdout")
+	fs.Var(&urls, "u", "Endpoint to fetch from; repeatable to fan in several sources and emit their merged, deduped, sorted union")
+	fs.DurationVar(&maxWait, "max-wait", 0, "Soft per-request deadline in fan-in mode (e.g. 500ms); 0 disables it")
+	fs.StringVar(&statePath, "state", "", "Path to a state file tracking fetched items, to resume an interrupted run")
+	fs.BoolVar(&force, "force", false, "Ignore any existing -state file and start fresh")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus /metrics on, e.g. :9090 (empty disables it)")
+	fs.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint to export traces to, e.g. localhost:4318 (empty disables tracing)")
 	fs.Parse(os.Args[1:])
 
+	if len(urls) == 0 {
+		urls = stringSliceFlag{apiStub}
+	}
+
 	fs.Usage = func() {
 		fmt.Println("ToDo Fetcher")
 		fmt.Println("Fetches a list of ToDo items from the jsonplaceholder.typicode.com service")
@@ -116,87 +252,210 @@ func main() {
 
 	slog.SetLogLoggerLevel(logLevel)
 
+	retry := RetryPolicy{
+		MaxAttempts: retries,
+		MaxElapsed:  time.Duration(retryMaxElapsed) * time.Second,
+		BaseDelay:   DefaultRetryPolicy().BaseDelay,
+		MaxDelay:    DefaultRetryPolicy().MaxDelay,
+	}
+
+	w, err := openOutput(out)
+	if err != nil {
+		slog.Error("Opening output", "error", err)
+		return
+	}
+	defer w.Close()
+
+	sink, err := newSink(output, w)
+	if err != nil {
+		slog.Error("Creating sink", "error", err)
+		return
+	}
+
+	state, err := OpenStateStore(statePath, force)
+	if err != nil {
+		slog.Error("Opening state store", "error", err)
+		return
+	}
+
+	if statePath != "" {
+		total := count * len(urls)
+		resumed := state.Len()
+		if resumed > total {
+			resumed = total
+		}
+		fmt.Printf("Resume: %d/%d items already fetched, %d pending\n", resumed, total, total-resumed)
+	}
+
+	var metrics *Metrics
+	if metricsAddr != "" {
+		reg := prometheus.NewRegistry()
+		metrics = NewMetrics(reg)
+
+		srv := newMetricsServer(metricsAddr, reg)
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Metrics server", "error", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Shutting down metrics server", "error", err)
+			}
+		}()
+	}
+
+	tracer, shutdownTracing, err := setupTracing(context.Background(), otlpEndpoint)
+	if err != nil {
+		slog.Error("Setting up tracing", "error", err)
+		return
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Error("Shutting down tracer", "error", err)
+		}
+	}()
+
 	if err := run(
+		urls,
 		count,
 		workerCount,
 		time.Duration(httpTimeout)*time.Second,
 		time.Duration(applicationDeadline)*time.Second,
+		maxWait,
 		debug,
+		retry,
+		NewHostRateLimiter(rps, burst),
+		sink,
+		state,
+		metrics,
+		tracer,
 	); err != nil {
 		slog.Error("Run error", "error", err.Error())
 	}
 
+	if err := sink.Flush(); err != nil {
+		slog.Error("Flushing output", "error", err)
+	}
+
+	if err := state.Compact(); err != nil {
+		slog.Error("Compacting state store", "error", err)
+	}
+
 }
 
 var ErrApplicationDeadlineExceeded = errors.New("application deadline exceeded")
 
-func run(count int, workers int, timeout time.Duration, deadline time.Duration, debug bool) error {
-	fetchToDo := ToDoFetcher(
-		&http.Client{
-			Timeout: timeout,
-		},
-		Even(apiStub),
-	)
+// run fetches count items from each of endpoints. With a single endpoint
+// (the common case) items are streamed into sink as they arrive. With more
+// than one, endpoints are fanned in round-robin under maxWait's soft
+// per-request deadline, and the deduped, ID-sorted union is written once
+// fetching completes, since the union can't be known until every source
+// has been drained. Any URL already marked done in state is skipped
+// rather than re-fetched, and newly completed URLs are recorded there. A
+// nil metrics disables instrumentation.
+func run(endpoints []string, count int, workers int, timeout time.Duration, deadline time.Duration, maxWait time.Duration, debug bool, retry RetryPolicy, limiter *HostRateLimiter, sink Sink, state *StateStore, metrics *Metrics, tracer trace.Tracer) error {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &instrumentedTransport{next: http.DefaultTransport, metrics: metrics, tracer: tracer},
+	}
+	fetch := httpFetcher{
+		fetch:   ToDoFetcher(client, retry, limiter, metrics),
+		maxWait: maxWait,
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), deadline)
 	defer cancel()
 
-	buf := make([]*ToDoItem, 64)
-
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(workers)
-
-loop:
-	for range count {
-		select {
-		case <-ctx.Done():
-			slog.Error("Execution exceeded deadline", "deadline", deadline)
-			break loop
-		default:
-			g.Go(func() error {
-				data, err := fetchToDo(ctx)
-				if err != nil {
-					return err
-				}
-
-				item := &ToDoItem{}
-				if err := json.Unmarshal(data, item); err != nil {
-					return err
-				}
-
-				// Generally speaking I would want to handle this case more
-				// gracefully, but it would massively depend on the business
-				// context. In this case, if there's an error with the payload,
-				// we are just going to log it and move on.
-				if item.ID <= 0 {
-					slog.Error("API", "item_id", item.ID, "error", "less than or equal to zero")
-					return nil
-				}
-
-				// For a more complex code base I'd probably encapsulate this
-				// in its own type, but given this is pretty lean, I think it's
-				// fine to have this inline.
-				for item.ID >= len(buf) {
-					newBuf := make([]*ToDoItem, len(buf)*2)
-					copy(newBuf, buf)
-					buf = newBuf
-				}
-
-				buf[item.ID] = item
-				return nil
-			})
-		}
+	sources := make([]Source, len(endpoints))
+	for i, endpoint := range endpoints {
+		sources[i] = NewBoundedSource(Even(endpoint), count)
 	}
+	src := Merge(sources)
+
+	pool := workerpool.New[string, *ToDoItem](workers, workers, fetch, toDoDecoder{metrics: metrics})
+	pool.SetHooks(workerpool.Hooks{
+		OnDequeue: metrics.observeQueueWait,
+		OnFinish:  func(dur time.Duration, _ error) { metrics.observeItem(dur) },
+	})
+	pool.Start(ctx)
+
+	go func() {
+		for {
+			url, ok := src.Next()
+			if !ok {
+				break
+			}
+			if state.Done(url) {
+				continue
+			}
+			metrics.incQueueDepth()
+			if err := pool.Submit(ctx, url); err != nil {
+				slog.Error("Execution exceeded deadline", "deadline", deadline)
+				break
+			}
+		}
+		if err := pool.Shutdown(ctx); err != nil {
+			slog.Error("Shutting down worker pool", "error", err)
+		}
+	}()
+
+	fanIn := len(endpoints) > 1
+	seen := make(map[int]bool)
+	var merged []*ToDoItem
+
+	for res := range pool.Results() {
+		if res.Err != nil {
+			// A terminal fetch error (e.g. a 404), a timed-out request, or
+			// a bad payload only means this one item is unfetchable, so we
+			// log and move on rather than aborting the rest of the batch.
+			slog.Error("Fetching todo item", "error", res.Err)
+			continue
+		}
+
+		if err := state.MarkDone(res.In); err != nil {
+			slog.Error("Recording state", "url", res.In, "error", err)
+		}
 
-	if err := g.Wait(); err != nil {
-		slog.Error("Fetching todo items", "error", err)
+		if !fanIn {
+			if err := sink.Write(res.Value); err != nil {
+				slog.Error("Writing item", "item_id", res.Value.ID, "error", err)
+			}
+			continue
+		}
+
+		if seen[res.Value.ID] {
+			continue
+		}
+		seen[res.Value.ID] = true
+		merged = append(merged, res.Value)
 	}
 
-	for _, item := range buf {
-		if item != nil {
-			fmt.Printf("ID: %-7dCompleted: %-9tTitle: %s\n", item.ID, item.Completed, item.Title)
+	if fanIn {
+		sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+		for _, item := range merged {
+			if err := sink.Write(item); err != nil {
+				slog.Error("Writing item", "item_id", item.ID, "error", err)
+			}
 		}
 	}
 
 	return nil
 }
+
+// stringSliceFlag accumulates repeated occurrences of a flag into a slice,
+// e.g. -u a -u b -u c.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
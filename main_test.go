@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 const endpoint = "https://jsonplaceholder.typicode.com/todos"
@@ -32,15 +34,19 @@ func TestToDoFetcher(t *testing.T) {
 		err    error
 	}{
 		{name: "StatusOK", status: http.StatusOK, err: nil},
-		{name: "StatusNon200", status: http.StatusNotFound, err: ErrNon200Response},
+		{name: "StatusNotFound", status: http.StatusNotFound, err: ErrTerminalResponse},
+		{name: "StatusTooManyRequests", status: http.StatusTooManyRequests, err: ErrRetryableResponse},
+		{name: "StatusInternalServerError", status: http.StatusInternalServerError, err: ErrRetryableResponse},
 	}
 
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 
-	fetch := ToDoFetcher(
-		srv.Client(),
-		Even(srv.URL),
-	)
+	// No retries here: a retryable status would otherwise hang the test
+	// waiting out the full backoff schedule.
+	noRetry := RetryPolicy{MaxAttempts: 1}
+
+	fetch := ToDoFetcher(srv.Client(), noRetry, nil, nil)
+	genURL := Even(srv.URL)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -51,10 +57,37 @@ func TestToDoFetcher(t *testing.T) {
 			)
 
 			ctx := context.Background()
-			_, err := fetch(ctx)
+			_, err := fetch(ctx, genURL())
 			if !errors.Is(err, tt.err) {
 				t.Errorf("Got error %v, want %v", err, tt.err)
 			}
 		})
 	}
 }
+
+func TestRetryPolicyRetriesTransientFailures(t *testing.T) {
+	var attempts int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	fetch := ToDoFetcher(
+		srv.Client(),
+		RetryPolicy{MaxAttempts: 5, MaxElapsed: time.Second, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+		nil,
+		nil,
+	)
+
+	if _, err := fetch(context.Background(), Even(srv.URL)()); err != nil {
+		t.Fatalf("Got error %v, want nil after retries succeed", err)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("Got %d attempts, want 3", got)
+	}
+}
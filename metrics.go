@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metrics holds the Prometheus instrumentation for a run. A nil *Metrics
+// is a no-op, so callers don't need to guard every call site behind
+// whether -metrics-addr was set.
+type Metrics struct {
+	requestsTotal   *prometheus.CounterVec
+	retriesTotal    prometheus.Counter
+	non200Total     *prometheus.CounterVec
+	decodeErrors    prometheus.Counter
+	requestDuration prometheus.Histogram
+	itemDuration    prometheus.Histogram
+	queueWait       prometheus.Histogram
+	inFlight        prometheus.Gauge
+	queueDepth      prometheus.Gauge
+}
+
+// NewMetrics registers the todofetcher collectors against reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	f := promauto.With(reg)
+
+	return &Metrics{
+		requestsTotal: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "todofetcher_requests_total",
+			Help: "Total HTTP requests issued, by host.",
+		}, []string{"host"}),
+		retriesTotal: f.NewCounter(prometheus.CounterOpts{
+			Name: "todofetcher_retries_total",
+			Help: "Total retry attempts after a transient failure.",
+		}),
+		non200Total: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "todofetcher_non200_responses_total",
+			Help: "Non-200 responses, by status code.",
+		}, []string{"status"}),
+		decodeErrors: f.NewCounter(prometheus.CounterOpts{
+			Name: "todofetcher_decode_errors_total",
+			Help: "Payloads that failed to decode into a ToDoItem.",
+		}),
+		requestDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Name:    "todofetcher_request_duration_seconds",
+			Help:    "Latency of a single HTTP request attempt.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		itemDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Name:    "todofetcher_item_duration_seconds",
+			Help:    "End-to-end latency to fetch and decode one item, including retries.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		queueWait: f.NewHistogram(prometheus.HistogramOpts{
+			Name:    "todofetcher_queue_wait_seconds",
+			Help:    "Time a task spent queued before a worker picked it up.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		inFlight: f.NewGauge(prometheus.GaugeOpts{
+			Name: "todofetcher_in_flight_workers",
+			Help: "Number of workers currently processing a fetch.",
+		}),
+		queueDepth: f.NewGauge(prometheus.GaugeOpts{
+			Name: "todofetcher_queue_depth",
+			Help: "Number of tasks currently queued.",
+		}),
+	}
+}
+
+func (m *Metrics) observeRequest(host string, dur time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requestsTotal.WithLabelValues(host).Inc()
+	m.requestDuration.Observe(dur.Seconds())
+}
+
+func (m *Metrics) incRetry() {
+	if m == nil {
+		return
+	}
+	m.retriesTotal.Inc()
+}
+
+func (m *Metrics) observeNon200(status int) {
+	if m == nil {
+		return
+	}
+	m.non200Total.WithLabelValues(strconv.Itoa(status)).Inc()
+}
+
+func (m *Metrics) incDecodeError() {
+	if m == nil {
+		return
+	}
+	m.decodeErrors.Inc()
+}
+
+func (m *Metrics) observeQueueWait(dur time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queueWait.Observe(dur.Seconds())
+	m.queueDepth.Dec()
+	m.inFlight.Inc()
+}
+
+func (m *Metrics) observeItem(dur time.Duration) {
+	if m == nil {
+		return
+	}
+	m.itemDuration.Observe(dur.Seconds())
+	m.inFlight.Dec()
+}
+
+func (m *Metrics) incQueueDepth() {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Inc()
+}
+
+// instrumentedTransport wraps an http.RoundTripper with request metrics and,
+// when tracer is non-nil, an OTLP span per attempt carrying endpoint,
+// attempt, and status_code attributes.
+type instrumentedTransport struct {
+	next    http.RoundTripper
+	metrics *Metrics
+	tracer  trace.Tracer
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt := attemptFromContext(req.Context())
+	ctx, span := t.tracer.Start(req.Context(), "todofetcher.fetch", trace.WithAttributes(
+		attribute.String("endpoint", req.URL.String()),
+		attribute.Int("attempt", attempt),
+	))
+	defer span.End()
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.metrics.observeRequest(req.URL.Host, time.Since(start))
+
+	if err != nil {
+		span.RecordError(err)
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		t.metrics.observeNon200(resp.StatusCode)
+	}
+
+	return resp, err
+}
+
+// attemptKey is the context key ToDoFetcher uses to record which retry
+// attempt a request belongs to, so the transport can tag spans with it.
+type attemptKey struct{}
+
+func contextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+func attemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// newMetricsServer starts an HTTP server exposing /metrics in Prometheus
+// text format on addr. The caller is responsible for shutting it down.
+func newMetricsServer(addr string, reg *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// setupTracing configures an OTLP/HTTP trace exporter pointed at endpoint
+// and returns a tracer plus a shutdown func to flush and close it. When
+// endpoint is empty, it returns otel's no-op tracer and a no-op shutdown, so
+// callers can unconditionally defer shutdown(ctx).
+func setupTracing(ctx context.Context, endpoint string) (trace.Tracer, func(context.Context) error, error) {
+	if endpoint == "" {
+		return otel.Tracer("todofetcher"), func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("todofetcher"),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("building resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	return tp.Tracer("todofetcher"), tp.Shutdown, nil
+}
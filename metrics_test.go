@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsObserve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+
+	m.observeRequest("example.com", 10*time.Millisecond)
+	m.incRetry()
+	m.observeNon200(http500)
+	m.incDecodeError()
+	m.incQueueDepth()
+	m.observeQueueWait(5 * time.Millisecond)
+	m.observeItem(20 * time.Millisecond)
+
+	got, err := testutil.GatherAndCount(reg)
+	if err != nil {
+		t.Fatalf("GatherAndCount error: %v", err)
+	}
+	if got == 0 {
+		t.Error("Got 0 collected metrics, want at least one after recording activity")
+	}
+
+	mf, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather error: %v", err)
+	}
+	var names []string
+	for _, f := range mf {
+		names = append(names, f.GetName())
+	}
+	if !strings.Contains(strings.Join(names, ","), "todofetcher_retries_total") {
+		t.Errorf("Got metric names %v, want todofetcher_retries_total among them", names)
+	}
+}
+
+const http500 = 500
+
+func TestNilMetricsIsANoOp(t *testing.T) {
+	var m *Metrics
+
+	m.observeRequest("example.com", time.Millisecond)
+	m.incRetry()
+	m.observeNon200(http500)
+	m.incDecodeError()
+	m.incQueueDepth()
+	m.observeQueueWait(time.Millisecond)
+	m.observeItem(time.Millisecond)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRetryableResponse and ErrTerminalResponse classify non-200 responses so
+// callers (and errgroup) can tell a transient failure worth retrying apart
+// from one that will never succeed. Use errors.Is against these sentinels;
+// the concrete error returned is always a *ResponseError.
+var (
+	ErrRetryableResponse = errors.New("retryable non-200 response")
+	ErrTerminalResponse  = errors.New("terminal non-200 response")
+)
+
+// ResponseError reports a non-200 HTTP response along with whether it's
+// worth retrying.
+type ResponseError struct {
+	StatusCode int
+	Retryable  bool
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("non-200 response: status=%d retryable=%t", e.StatusCode, e.Retryable)
+}
+
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrRetryableResponse:
+		return e.Retryable
+	case ErrTerminalResponse:
+		return !e.Retryable
+	}
+	return false
+}
+
+// retryableStatus reports whether status is a transient failure (429 or any
+// 5xx) as opposed to a terminal one (e.g. 404, 401).
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// RetryPolicy controls how a fetch is retried after a transient failure:
+// a network error, 429, or 5xx. Backoff is exponential with full jitter,
+// capped at MaxDelay, and Retry-After is honored verbatim when present.
+// Retries stop once either MaxAttempts or MaxElapsed is reached.
+type RetryPolicy struct {
+	MaxAttempts int
+	MaxElapsed  time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy returns sane defaults for hitting a public API.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		MaxElapsed:  2 * time.Minute,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// nextDelay returns how long to wait before the given attempt (1-indexed),
+// honoring retryAfter when the server specified one.
+func (p RetryPolicy) nextDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	max := p.MaxDelay
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+
+	delay := p.BaseDelay * time.Duration(1<<uint(attempt-1))
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// done reports whether attempt (about to be made) exceeds the policy, given
+// elapsed time since the first attempt.
+func (p RetryPolicy) exhausted(attempt int, elapsed time.Duration) bool {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return true
+	}
+	if p.MaxElapsed > 0 && elapsed >= p.MaxElapsed {
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header, which may be given as a
+// number of seconds or an HTTP-date. It returns 0 if the header is absent
+// or unparseable.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// HostRateLimiter enforces a token-bucket rate limit per destination host,
+// so a run targeting several endpoints with different quotas (e.g. 10 rps
+// on one, 20 rps on another) doesn't exceed any single one of them.
+type HostRateLimiter struct {
+	rps   rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewHostRateLimiter returns a limiter allowing rps requests per second per
+// host, with the given burst. A non-positive rps disables limiting.
+func NewHostRateLimiter(rps float64, burst int) *HostRateLimiter {
+	return &HostRateLimiter{
+		rps:      rate.Limit(rps),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Wait blocks until a token is available for host, or ctx is done.
+func (h *HostRateLimiter) Wait(ctx context.Context, host string) error {
+	if h == nil || h.rps <= 0 {
+		return nil
+	}
+	return h.limiterFor(host).Wait(ctx)
+}
+
+func (h *HostRateLimiter) limiterFor(host string) *rate.Limiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, ok := h.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(h.rps, h.burst)
+		h.limiters[host] = l
+	}
+	return l
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSinks(t *testing.T) {
+	item := &ToDoItem{UserID: 1, ID: 2, Title: "write tests", Completed: true}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "text", want: "ID: 2      Completed: true     Title: write tests\n"},
+		{format: "ndjson", want: `{"userId":1,"id":2,"title":"write tests","completed":true}` + "\n"},
+		{format: "csv", want: "id,userId,title,completed\n2,1,write tests,true\n"},
+		{format: "null", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			sink, err := newSink(tt.format, &buf)
+			if err != nil {
+				t.Fatalf("newSink(%q) error: %v", tt.format, err)
+			}
+
+			if err := sink.Write(item); err != nil {
+				t.Fatalf("Write error: %v", err)
+			}
+			if err := sink.Flush(); err != nil {
+				t.Fatalf("Flush error: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Got output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSinkUnknownFormat(t *testing.T) {
+	if _, err := newSink("xml", &bytes.Buffer{}); err == nil {
+		t.Error("Got nil error for unknown format, want an error")
+	} else if !strings.Contains(err.Error(), "xml") {
+		t.Errorf("Got error %q, want it to mention the format", err)
+	}
+}
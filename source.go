@@ -0,0 +1,61 @@
+package main
+
+// Source yields a bounded sequence of URLs to fetch. Next returns ok=false
+// once the source is exhausted.
+type Source interface {
+	Next() (url string, ok bool)
+}
+
+// BoundedSource turns an otherwise-infinite URLGenerator into a Source that
+// stops after count URLs.
+type BoundedSource struct {
+	urlFn URLGenerator
+	count int
+	n     int
+}
+
+// NewBoundedSource returns a Source that calls urlFn count times before
+// exhausting.
+func NewBoundedSource(urlFn URLGenerator, count int) *BoundedSource {
+	return &BoundedSource{urlFn: urlFn, count: count}
+}
+
+func (s *BoundedSource) Next() (string, bool) {
+	if s.n >= s.count {
+		return "", false
+	}
+	s.n++
+	return s.urlFn(), true
+}
+
+// mergedSource round-robins Next across a set of Sources, dropping each one
+// as it's exhausted, so a slow or empty source doesn't starve the others.
+type mergedSource struct {
+	sources []Source
+	i       int
+}
+
+// Merge combines sources into a single Source, fed round-robin. The result
+// is exhausted once every underlying source is.
+func Merge(sources []Source) Source {
+	active := make([]Source, len(sources))
+	copy(active, sources)
+	return &mergedSource{sources: active}
+}
+
+func (m *mergedSource) Next() (string, bool) {
+	for len(m.sources) > 0 {
+		if m.i >= len(m.sources) {
+			m.i = 0
+		}
+
+		url, ok := m.sources[m.i].Next()
+		if ok {
+			m.i++
+			return url, true
+		}
+
+		m.sources = append(m.sources[:m.i], m.sources[m.i+1:]...)
+	}
+	return "", false
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestBoundedSourceStopsAtCount(t *testing.T) {
+	src := NewBoundedSource(Even("e"), 3)
+
+	var got []string
+	for {
+		url, ok := src.Next()
+		if !ok {
+			break
+		}
+		got = append(got, url)
+	}
+
+	want := []string{"e/2", "e/4", "e/6"}
+	if len(got) != len(want) {
+		t.Fatalf("Got %d urls, want %d: %v", len(got), len(want), got)
+	}
+	for i, u := range want {
+		if got[i] != u {
+			t.Errorf("Got url %d = %q, want %q", i, got[i], u)
+		}
+	}
+}
+
+func TestMergeRoundRobinsAndDropsExhausted(t *testing.T) {
+	a := NewBoundedSource(Even("a"), 1)
+	b := NewBoundedSource(Even("b"), 3)
+
+	merged := Merge([]Source{a, b})
+
+	var got []string
+	for {
+		url, ok := merged.Next()
+		if !ok {
+			break
+		}
+		got = append(got, url)
+	}
+
+	want := []string{"a/2", "b/2", "b/4", "b/6"}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i, u := range want {
+		if got[i] != u {
+			t.Errorf("Got url %d = %q, want %q", i, got[i], u)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// StateStore persists, as a plain newline-delimited file, the set of
+// endpoint URLs that have already been fetched successfully, so an
+// interrupted run can resume by skipping what's already done instead of
+// re-fetching everything. A nil *StateStore (no -state flag) behaves as an
+// always-empty, no-op store.
+type StateStore struct {
+	mu   sync.Mutex
+	path string
+	done map[string]bool
+}
+
+// OpenStateStore loads path's existing entries, or starts empty if force is
+// true, path is empty, or the file doesn't exist yet.
+func OpenStateStore(path string, force bool) (*StateStore, error) {
+	s := &StateStore{path: path, done: make(map[string]bool)}
+	if path == "" {
+		return s, nil
+	}
+
+	if force {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, f.Close()
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key := scanner.Text(); key != "" {
+			s.done[key] = true
+		}
+	}
+	return s, scanner.Err()
+}
+
+// Done reports whether key has already been recorded as fetched.
+func (s *StateStore) Done(key string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[key]
+}
+
+// MarkDone records key as fetched and appends it to the state file so a
+// crash mid-run doesn't lose completed work.
+func (s *StateStore) MarkDone(key string) error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done[key] {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, key); err != nil {
+		return err
+	}
+
+	s.done[key] = true
+	return nil
+}
+
+// Len reports how many keys are currently recorded as done.
+func (s *StateStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.done)
+}
+
+// Compact rewrites the state file with exactly the current set of done
+// keys, one per line, so repeated append-only runs against the same file
+// don't grow it with duplicate entries.
+func (s *StateStore) Compact() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for key := range s.done {
+		if _, err := fmt.Fprintln(w, key); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStateStoreResumesAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	s, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenStateStore error: %v", err)
+	}
+	if s.Done("a") {
+		t.Error("Got Done(a) = true on empty store, want false")
+	}
+
+	if err := s.MarkDone("a"); err != nil {
+		t.Fatalf("MarkDone error: %v", err)
+	}
+	if !s.Done("a") {
+		t.Error("Got Done(a) = false after MarkDone, want true")
+	}
+
+	reopened, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenStateStore (reopen) error: %v", err)
+	}
+	if !reopened.Done("a") {
+		t.Error("Got Done(a) = false after reopening, want true (resumed from disk)")
+	}
+	if reopened.Len() != 1 {
+		t.Errorf("Got Len() = %d, want 1", reopened.Len())
+	}
+}
+
+func TestStateStoreForceIgnoresExistingState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	s, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenStateStore error: %v", err)
+	}
+	if err := s.MarkDone("a"); err != nil {
+		t.Fatalf("MarkDone error: %v", err)
+	}
+
+	forced, err := OpenStateStore(path, true)
+	if err != nil {
+		t.Fatalf("OpenStateStore (force) error: %v", err)
+	}
+	if forced.Done("a") {
+		t.Error("Got Done(a) = true under -force, want false")
+	}
+}
+
+func TestStateStoreCompactDropsDuplicateAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	// Simulate two independent runs against the same file, each unaware of
+	// the other's writes, so the file ends up with a duplicate line.
+	for i := 0; i < 2; i++ {
+		s, err := OpenStateStore(path, false)
+		if err != nil {
+			t.Fatalf("OpenStateStore error: %v", err)
+		}
+		if err := s.MarkDone("a"); err != nil {
+			t.Fatalf("MarkDone error: %v", err)
+		}
+	}
+
+	s, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenStateStore error: %v", err)
+	}
+	if got := s.Len(); got != 1 {
+		t.Fatalf("Got Len() = %d before compaction, want 1 (duplicate line collapses on load)", got)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if got := strings.Count(string(data), "a\n"); got != 1 {
+		t.Errorf("Got %d occurrences of %q in compacted file, want 1", got, "a")
+	}
+}
+
+func TestNilStateStoreIsANoOp(t *testing.T) {
+	var s *StateStore
+
+	if s.Done("a") {
+		t.Error("Got Done(a) = true on nil store, want false")
+	}
+	if err := s.MarkDone("a"); err != nil {
+		t.Errorf("MarkDone on nil store error: %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Got Len() = %d on nil store, want 0", s.Len())
+	}
+	if err := s.Compact(); err != nil {
+		t.Errorf("Compact on nil store error: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package workerpool provides a bounded, generic worker pool for fetching
+// and decoding any paginated/ID-addressed resource: a fixed number of
+// long-lived goroutines pull work from a buffered queue, fetch it, decode
+// it, and publish the result, rather than spawning one goroutine per item.
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fetcher retrieves the raw bytes for a unit of work of type TIn.
+type Fetcher[TIn any] interface {
+	Fetch(ctx context.Context, in TIn) ([]byte, error)
+}
+
+// Decoder turns raw bytes into a TOut.
+type Decoder[TOut any] interface {
+	Decode(data []byte) (TOut, error)
+}
+
+// Result is what a worker publishes for each TIn it processes. In is
+// carried through so callers can correlate a Result back to the work that
+// produced it, e.g. to checkpoint it as complete.
+type Result[TIn, TOut any] struct {
+	In    TIn
+	Value TOut
+	Err   error
+}
+
+// Stats is a point-in-time snapshot of pool activity.
+type Stats struct {
+	QueueDepth int64
+	InFlight   int64
+	Completed  int64
+}
+
+// Hooks are optional observability callbacks. A nil field is simply
+// skipped, so callers only need to set the ones they care about (e.g. to
+// feed a metrics library) without the pool depending on one.
+type Hooks struct {
+	// OnDequeue reports how long a task waited in the queue before a
+	// worker picked it up.
+	OnDequeue func(waited time.Duration)
+	// OnFinish reports how long a task took to fetch and decode, and its
+	// resulting error, if any.
+	OnFinish func(dur time.Duration, err error)
+}
+
+// task wraps a submitted TIn with the time it was enqueued, so workers can
+// report queue wait time via Hooks.OnDequeue.
+type task[TIn any] struct {
+	in       TIn
+	queuedAt time.Time
+}
+
+// Pool runs a fixed number of workers that fetch and decode TIn into TOut.
+type Pool[TIn, TOut any] struct {
+	fetcher Fetcher[TIn]
+	decoder Decoder[TOut]
+	workers int
+	hooks   Hooks
+
+	tasks   chan task[TIn]
+	results chan Result[TIn, TOut]
+	wg      sync.WaitGroup
+
+	queueDepth atomic.Int64
+	inFlight   atomic.Int64
+	completed  atomic.Int64
+}
+
+// New returns a Pool with the given number of workers and queue size. The
+// pool does not start processing until Start is called.
+func New[TIn, TOut any](workers, queueSize int, fetcher Fetcher[TIn], decoder Decoder[TOut]) *Pool[TIn, TOut] {
+	return &Pool[TIn, TOut]{
+		fetcher: fetcher,
+		decoder: decoder,
+		workers: workers,
+		tasks:   make(chan task[TIn], queueSize),
+		results: make(chan Result[TIn, TOut], queueSize),
+	}
+}
+
+// SetHooks installs observability callbacks. Call before Start.
+func (p *Pool[TIn, TOut]) SetHooks(hooks Hooks) {
+	p.hooks = hooks
+}
+
+// Start launches the pool's workers. It returns immediately.
+func (p *Pool[TIn, TOut]) Start(ctx context.Context) {
+	for range p.workers {
+		p.wg.Add(1)
+		go p.worker(ctx)
+	}
+}
+
+func (p *Pool[TIn, TOut]) worker(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t, ok := <-p.tasks:
+			if !ok {
+				return
+			}
+			p.queueDepth.Add(-1)
+			if p.hooks.OnDequeue != nil {
+				p.hooks.OnDequeue(time.Since(t.queuedAt))
+			}
+			p.process(ctx, t.in)
+		}
+	}
+}
+
+func (p *Pool[TIn, TOut]) process(ctx context.Context, in TIn) {
+	p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+
+	start := time.Now()
+	data, err := p.fetcher.Fetch(ctx, in)
+	var out TOut
+	if err == nil {
+		out, err = p.decoder.Decode(data)
+	}
+	p.completed.Add(1)
+
+	if p.hooks.OnFinish != nil {
+		p.hooks.OnFinish(time.Since(start), err)
+	}
+
+	select {
+	case p.results <- Result[TIn, TOut]{In: in, Value: out, Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Submit enqueues in for processing, blocking until a slot is free or ctx
+// is done.
+func (p *Pool[TIn, TOut]) Submit(ctx context.Context, in TIn) error {
+	p.queueDepth.Add(1)
+	select {
+	case p.tasks <- task[TIn]{in: in, queuedAt: time.Now()}:
+		return nil
+	case <-ctx.Done():
+		p.queueDepth.Add(-1)
+		return ctx.Err()
+	}
+}
+
+// Results returns the channel Results are published on. Callers should
+// range over it until Shutdown closes it.
+func (p *Pool[TIn, TOut]) Results() <-chan Result[TIn, TOut] {
+	return p.results
+}
+
+// Shutdown stops accepting new work, waits for in-flight tasks to drain (or
+// ctx to expire), and closes the results channel. It is the caller's
+// responsibility to have stopped calling Submit before calling Shutdown.
+//
+// The results channel is only ever closed after every worker has returned:
+// workers can still be blocked sending on it when ctx is done (process's own
+// select observes ctx.Done() and exits promptly, but may already be past
+// that select into the channel send), so closing it early would race a
+// worker's send and panic. The wg.Wait goroutine is the sole closer.
+func (p *Pool[TIn, TOut]) Shutdown(ctx context.Context) error {
+	close(p.tasks)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(p.results)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a point-in-time snapshot of queue depth, in-flight tasks,
+// and completed tasks.
+func (p *Pool[TIn, TOut]) Stats() Stats {
+	return Stats{
+		QueueDepth: p.queueDepth.Load(),
+		InFlight:   p.inFlight.Load(),
+		Completed:  p.completed.Load(),
+	}
+}
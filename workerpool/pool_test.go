@@ -0,0 +1,131 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type intFetcher struct{}
+
+func (intFetcher) Fetch(_ context.Context, in int) ([]byte, error) {
+	if in < 0 {
+		return nil, errors.New("negative input")
+	}
+	return []byte(strconv.Itoa(in * 2)), nil
+}
+
+type intDecoder struct{}
+
+func (intDecoder) Decode(data []byte) (int, error) {
+	return strconv.Atoi(string(data))
+}
+
+func TestPoolProcessesAllSubmittedWork(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	pool := New[int, int](3, 10, intFetcher{}, intDecoder{})
+	pool.Start(ctx)
+
+	const n = 20
+	go func() {
+		for i := range n {
+			if err := pool.Submit(ctx, i); err != nil {
+				t.Errorf("Submit(%d) error: %v", i, err)
+			}
+		}
+		if err := pool.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown error: %v", err)
+		}
+	}()
+
+	got := make(map[int]bool)
+	for res := range pool.Results() {
+		if res.Err != nil {
+			t.Fatalf("Unexpected result error: %v", res.Err)
+		}
+		got[res.Value] = true
+	}
+
+	if len(got) != n {
+		t.Fatalf("Got %d distinct results, want %d", len(got), n)
+	}
+	for i := range n {
+		if !got[i*2] {
+			t.Errorf("Missing expected result %d", i*2)
+		}
+	}
+}
+
+type slowFetcher struct {
+	delay time.Duration
+}
+
+func (f slowFetcher) Fetch(ctx context.Context, in int) ([]byte, error) {
+	select {
+	case <-time.After(f.delay):
+		return []byte(strconv.Itoa(in)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TestShutdownCancelledContextDoesNotCloseResultsWhileWorkersRun guards
+// against closing the results channel while a worker can still be blocked
+// sending on it: Shutdown must let the wg.Wait goroutine own the close,
+// even when ctx is already done, rather than closing eagerly in the
+// ctx.Done() branch, or a worker's send on pool.go's process() races a
+// send on a closed channel and panics.
+func TestShutdownCancelledContextDoesNotCloseResultsWhileWorkersRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := New[int, int](2, 2, slowFetcher{delay: 50 * time.Millisecond}, intDecoder{})
+	pool.Start(ctx)
+
+	for i := range 2 {
+		if err := pool.Submit(ctx, i); err != nil {
+			t.Fatalf("Submit(%d) error: %v", i, err)
+		}
+	}
+
+	// Drain in the background so a worker's send on p.results, once its
+	// slow fetch finishes, doesn't block forever.
+	drained := make(chan struct{})
+	go func() {
+		for range pool.Results() {
+		}
+		close(drained)
+	}()
+
+	cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Got Shutdown error %v, want context.Canceled", err)
+	}
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("Results channel was never closed after workers finished")
+	}
+}
+
+func TestPoolReportsFetchErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	pool := New[int, int](1, 1, intFetcher{}, intDecoder{})
+	pool.Start(ctx)
+
+	go func() {
+		_ = pool.Submit(ctx, -1)
+		_ = pool.Shutdown(ctx)
+	}()
+
+	res := <-pool.Results()
+	if res.Err == nil {
+		t.Error("Got nil error for negative input, want an error")
+	}
+}